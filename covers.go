@@ -4,7 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/token"
+	"io"
+	"os"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,12 +19,66 @@ import (
 )
 
 // TagPrefix is the prefix for machine-readable comments.
-// For example "//covers:DescriptiveName"
+// For example "//covers:DescriptiveName". Two richer forms are also
+// recognized: "//covers:func Name" immediately before a function binds Name
+// to every block in that function, and a "//covers:begin Name" /
+// "//covers:end Name" pair binds Name to every block in between.
 const TagPrefix = "//covers:"
 
+// tagKind distinguishes the three //covers: comment forms parseTag
+// recognizes.
+type tagKind int
+
+const (
+	tagPlain tagKind = iota // //covers:Name — binds to the single block containing the comment
+	tagFunc                 // //covers:func Name — binds to every block inside the following FuncDecl
+	tagBegin                // //covers:begin Name — opens a //covers:end Name pair
+	tagEnd                  // //covers:end Name — closes a //covers:begin Name pair
+)
+
+// parsedTag is a //covers: comment broken into its kind and tag name.
+type parsedTag struct {
+	kind tagKind
+	name string
+}
+
+// parseTag parses the text following TagPrefix into a parsedTag.
+func parseTag(text string) parsedTag {
+	body := strings.TrimPrefix(text, TagPrefix)
+	if name, ok := strings.CutPrefix(body, "func "); ok {
+		return parsedTag{kind: tagFunc, name: strings.TrimSpace(name)}
+	}
+	if name, ok := strings.CutPrefix(body, "begin "); ok {
+		return parsedTag{kind: tagBegin, name: strings.TrimSpace(name)}
+	}
+	if name, ok := strings.CutPrefix(body, "end "); ok {
+		return parsedTag{kind: tagEnd, name: strings.TrimSpace(name)}
+	}
+	return parsedTag{kind: tagPlain, name: body}
+}
+
+// aggregateRange binds a tag name to every coverage block inside [start,end]
+// of a single file. It backs //covers:func and //covers:begin/end tags,
+// which may span many blocks rather than the one a plain tag resolves to.
+type aggregateRange struct {
+	name  string
+	file  string
+	start token.Position
+	end   token.Position
+}
+
 var (
 	ErrNoCoverage = errors.New("coverage not enabled (-cover)")
 	ErrWrongMode  = errors.New("mode not supported for operation")
+	// ErrLiveCoverageUnavailable is returned by Setup when -cover is active
+	// but no addressable per-block counters are available to read from
+	// in-process. On Go 1.20+, "go test -cover" instruments packages via
+	// the redesigned runtime/coverage scheme instead of populating the
+	// legacy testing.cover linkname, and that scheme only flushes counters
+	// to disk when the test binary exits, so there is no live mid-test
+	// counter left to read. Use FromProfile against a -coverprofile
+	// written by the same test run instead.
+	ErrLiveCoverageUnavailable = errors.New("no live per-block coverage counters available for this Go toolchain's -cover instrumentation; use FromProfile against a -coverprofile instead")
 )
 
 // cover is a way too get at an unexported identifer in the testing package.
@@ -28,7 +86,19 @@ var (
 //go:linkname cover testing.cover
 var cover testing.Cover
 
+// tHelper is the subset of testing.TB that Counters needs to report setup
+// failures. testing.TB satisfies it structurally; Counters built by
+// FromProfile, which has no *testing.T to report through, use profileTB
+// instead.
+type tHelper interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
 // May loads a Counters struct if coverage is enabled. Otherwise the struct is non-functional.
+// As of this package's current Go toolchain support, live in-process coverage is never actually
+// available (see ErrLiveCoverageUnavailable), so the returned Counters' Tag/TagHit are always
+// no-ops; use FromProfile for live assertions instead.
 func May(t testing.TB) *Counters {
 	t.Helper()
 
@@ -37,7 +107,10 @@ func May(t testing.TB) *Counters {
 }
 
 // Should loads a Counters struct if coverage is enabled. Otherwise the struct is non-functional.
-// It will log if coverage was not enabled via command line options.
+// It will log if coverage was not enabled via command line options. As of this package's current
+// Go toolchain support, live in-process coverage is never actually available (see
+// ErrLiveCoverageUnavailable), so it will always log and return a Counters whose Tag/TagHit are
+// no-ops; use FromProfile for live assertions instead.
 func Should(t testing.TB) *Counters {
 	t.Helper()
 
@@ -49,6 +122,9 @@ func Should(t testing.TB) *Counters {
 }
 
 // Must loads a Counters struct if coverage is enabled. It will fail the test is coverage is unavailable.
+// As of this package's current Go toolchain support, live in-process coverage is never actually
+// available (see ErrLiveCoverageUnavailable), so Must always fails the test; use FromProfile for
+// live assertions instead.
 func Must(t testing.TB) *Counters {
 	t.Helper()
 
@@ -59,46 +135,81 @@ func Must(t testing.TB) *Counters {
 	return c
 }
 
-// Setup initializes a Counters object.
+// Setup initializes a Counters object. Without -cover it returns ErrNoCoverage, and under an
+// unsupported -covermode it returns an ErrWrongMode error; otherwise it returns
+// ErrLiveCoverageUnavailable on every currently supported Go toolchain (see the package doc).
+// Use FromProfile for live assertions instead.
 func Setup(t testing.TB) (*Counters, error) {
 	t.Helper()
 
 	c := &Counters{
 		tb:       t,
-		counters: map[string]*uint32{},
+		counters: map[string][]*uint32{},
 	}
 
 	var err error
 	switch cm := testing.CoverMode(); cm {
-	case "count", "atomic":
+	case "count", "atomic", "set":
 		c.isEnabled = true
+		c.mode = cm
 	case "":
 		err = ErrNoCoverage
-	case "set":
-		fallthrough
 	default:
-		err = fmt.Errorf("%v; was \"%s\". Try -covermode atomic|count", ErrWrongMode, cm)
+		err = fmt.Errorf("%v; was \"%s\". Try -covermode atomic|count|set", ErrWrongMode, cm)
 	}
 
-	c.counters = initCounters(t, c.isEnabled)
+	var liveErr error
+	c.counters, c.locations, liveErr = initCounters(t, c.isEnabled)
 	c.Snapshot = c.NewSnapshot()
 
 	if err != nil {
 		return c, err
 	}
+	if liveErr != nil {
+		return c, liveErr
+	}
 
 	return c, nil
 }
 
 // Counters represents a mapping of machine-readable "//covers:" tags to coverage counters.
+// A tag maps to more than one counter when it aggregates several blocks, via //covers:func
+// or a //covers:begin/end pair; a plain //covers:Name tag maps to exactly one. When coverage is
+// off or no live counters are available, every tag maps to an empty slice.
 type Counters struct {
 	before    testing.Cover
-	tb        testing.TB
-	counters  map[string]*uint32
+	tb        tHelper
+	counters  map[string][]*uint32
+	locations map[string][]tagLocation // tag -> source blocks, for WriteProfile
 	isEnabled bool
+	mode      string // the active -covermode; "count", "atomic", or "set"
 	Snapshot
 }
 
+// tagLocation records the source block a //covers: tag resolved to, so
+// WriteProfile can emit a coverprofile line for it without re-walking the
+// AST.
+type tagLocation struct {
+	file string
+	coverageBlock
+	numStmt int
+}
+
+// addCounter appends ctr to values[target]/locations[target]. It is used for
+// //covers:func and //covers:begin/end tags, which may aggregate many
+// counters; revisiting the exact same counter (e.g. because a package is
+// loaded twice, with and without its test variant) is a no-op rather than
+// double-counting the block.
+func addCounter(values map[string][]*uint32, locations map[string][]tagLocation, target string, ctr *uint32, loc tagLocation) {
+	for _, existing := range values[target] {
+		if existing == ctr {
+			return
+		}
+	}
+	values[target] = append(values[target], ctr)
+	locations[target] = append(locations[target], loc)
+}
+
 // Snapshot represents the state of the counters at a point in time.
 type Snapshot struct {
 	counters *Counters
@@ -110,14 +221,15 @@ func (c *Counters) NewSnapshot() Snapshot {
 	c.tb.Helper()
 
 	ss := make(map[*uint32]uint32, len(c.counters))
-	for tag := range c.counters {
-		addr := c.counters[tag]
-		var val uint32
-		if addr != nil {
-			// This code path is for when coverage is off
-			val = atomic.LoadUint32(addr)
+	for _, addrs := range c.counters {
+		for _, addr := range addrs {
+			var val uint32
+			if addr != nil {
+				// This code path is for when coverage is off
+				val = atomic.LoadUint32(addr)
+			}
+			ss[addr] = val
 		}
-		ss[addr] = val
 	}
 	return Snapshot{
 		counters: c,
@@ -127,35 +239,244 @@ func (c *Counters) NewSnapshot() Snapshot {
 
 // Tag retrieves the change in a counter's value since a snapshot and runs a function on that value.
 // Functions may not be evaluated if we are running in an optional mode (Should or May).
+// Tag is not meaningful under -covermode=set, which only ever records whether a block ran, not how
+// many times; use TagHit instead.
+// If tag aggregates several blocks (//covers:func or //covers:begin/end), delta is their sum, the
+// same way "go tool cover -func" sums a function's blocks.
 func (ss *Snapshot) Tag(tag string, f func(delta uint32)) {
 	ss.counters.tb.Helper()
 
-	addr, ok := ss.counters.counters[tag]
+	addrs, ok := ss.counters.counters[tag]
 	if !ok {
 		ss.counters.tb.Fatalf("tag not found in counters: %s", tag)
 	}
-	oldValue, ok := ss.values[addr]
-	if !ok {
-		ss.counters.tb.Fatalf("tag not found: %s", tag)
+	if len(addrs) == 0 {
+		// This code path is for when coverage is off (May or Should run
+		// without -cover); mirror TagHit and no-op rather than asserting
+		// mode, since there's nothing to be wrong about a mode for.
+		return
 	}
 
-	if addr == nil {
-		// This code path is for when coverage is off
-		return
+	if ss.counters.mode == "set" {
+		ss.counters.tb.Fatalf("%v; Tag needs a counting -covermode, was \"set\". Use TagHit instead", ErrWrongMode)
+	}
+
+	var delta uint32
+	for _, addr := range addrs {
+		oldValue, ok := ss.values[addr]
+		if !ok {
+			ss.counters.tb.Fatalf("tag not found: %s", tag)
+		}
+		value := atomic.LoadUint32(addr)
+		delta += value - oldValue
 	}
-	value := atomic.LoadUint32(addr)
-	delta := value - oldValue
 	f(delta)
 }
 
+// TagHit reports whether a block has executed under -covermode=set, where counters only ever
+// record 0 or 1 rather than a count. hit is current != 0 && old == 0: the block transitioned from
+// unexecuted to executed since the snapshot. Counters are shared by the whole test binary, so
+// old is 1 if an earlier test already ran the block; hit is then false even if the block runs
+// again during this test. TagHit is only valid under -covermode=set; use Tag for count/atomic modes.
+// f is not evaluated if we are running in an optional mode (Should or May) with coverage disabled,
+// the same as Tag.
+// If tag aggregates several blocks (//covers:func or //covers:begin/end), hit is true if any one
+// of them transitioned.
+func (ss *Snapshot) TagHit(tag string, f func(hit bool)) {
+	ss.counters.tb.Helper()
+
+	addrs, ok := ss.counters.counters[tag]
+	if !ok {
+		ss.counters.tb.Fatalf("tag not found in counters: %s", tag)
+	}
+	if len(addrs) == 0 {
+		// This code path is for when coverage is off (May or Should run
+		// without -cover); mirror Tag and no-op rather than asserting mode.
+		return
+	}
+
+	if ss.counters.mode != "set" {
+		ss.counters.tb.Fatalf("%v; TagHit needs -covermode=set, was \"%s\". Use Tag instead", ErrWrongMode, ss.counters.mode)
+	}
+
+	var hit bool
+	for _, addr := range addrs {
+		oldValue, ok := ss.values[addr]
+		if !ok {
+			ss.counters.tb.Fatalf("tag not found: %s", tag)
+		}
+		value := atomic.LoadUint32(addr)
+		if value != 0 && oldValue == 0 {
+			hit = true
+		}
+	}
+	f(hit)
+}
+
+// WriteProfile emits a standard go coverprofile (the "mode: ..." header
+// followed by "file:line.col,line.col numStmt count" lines, as consumed by
+// "go tool cover -html=" or "-func=") restricted to the blocks referenced by
+// //covers: tags. The output is byte-for-byte what "go tool cover" expects,
+// with no extra columns; use ProfileTags to look up which tag a line came
+// from.
+func (c *Counters) WriteProfile(w io.Writer) error {
+	c.tb.Helper()
+
+	if _, err := fmt.Fprintf(w, "mode: %s\n", c.mode); err != nil {
+		return err
+	}
+
+	tags := make([]string, 0, len(c.locations))
+	for tag := range c.locations {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		locs := c.locations[tag]
+		addrs := c.counters[tag]
+		for i, loc := range locs {
+			var count uint32
+			if i < len(addrs) && addrs[i] != nil {
+				count = atomic.LoadUint32(addrs[i])
+			}
+			_, err := fmt.Fprintf(w, "%s:%d.%d,%d.%d %d %d\n",
+				loc.file, loc.line0, loc.col0, loc.line1, loc.col1, loc.numStmt, count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ProfileTags returns, for each //covers: tag, the coverprofile block keys
+// ("file:line.col,line.col") WriteProfile emitted for it, in the same order
+// as WriteProfile's output. WriteProfile itself carries no tag information
+// so its output stays a valid coverprofile; callers that need to associate
+// a profile line with the tag it came from do it through this side-channel
+// instead.
+func (c *Counters) ProfileTags() map[string][]string {
+	out := make(map[string][]string, len(c.locations))
+	for tag, locs := range c.locations {
+		keys := make([]string, len(locs))
+		for i, loc := range locs {
+			keys[i] = blockKey(loc.file, loc.coverageBlock)
+		}
+		out[tag] = keys
+	}
+	return out
+}
+
+// WriteProfileFile is WriteProfile but writes to the named file, creating it
+// if it does not already exist.
+func (c *Counters) WriteProfileFile(path string) (err error) {
+	c.tb.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("covers: creating profile file: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	return c.WriteProfile(f)
+}
+
+// scanPackageTags walks one package's syntax trees for //covers: comments,
+// classifying each as a plain single-block tag (recorded in
+// commentMap/targetMap, matched against blocks the same way as always) or a
+// //covers:func / //covers:begin/end range (recorded in ranges, matched by
+// containment instead). It is shared by initCounters and FromProfile, which
+// resolve tags against two different views of coverage data.
+func scanPackageTags(t tHelper, pkg *packages.Package, coverageEnabled bool, values map[string][]*uint32) (commentMap map[string][]*ast.Comment, targetMap map[*ast.Comment]string, ranges []aggregateRange) {
+	commentMap = make(map[string][]*ast.Comment)
+	targetMap = make(map[*ast.Comment]string)
+	pendingBegins := make(map[string]aggregateRange)
+	dir := pkg.Module.Dir
+	modPath := pkg.Module.Path
+
+	for i, f := range pkg.CompiledGoFiles {
+		if !strings.HasPrefix(f, dir) {
+			continue
+		}
+		pathWithModule := strings.Replace(f, dir, modPath, 1)
+		syntax := pkg.Syntax[i]
+		commentMapEntry := commentMap[pathWithModule]
+		for _, commentGroup := range syntax.Comments {
+			for _, c := range commentGroup.List {
+				if !strings.HasPrefix(c.Text, TagPrefix) {
+					continue
+				}
+				tag := parseTag(c.Text)
+				switch tag.kind {
+				case tagPlain:
+					commentMapEntry = append(commentMapEntry, c)
+					targetMap[c] = tag.name
+				case tagBegin:
+					pendingBegins[tag.name] = aggregateRange{
+						name:  tag.name,
+						file:  pathWithModule,
+						start: pkg.Fset.Position(c.Pos()),
+					}
+				case tagEnd:
+					begin, ok := pendingBegins[tag.name]
+					if !ok {
+						t.Fatalf("covers:end %s with no matching covers:begin", tag.name)
+					}
+					delete(pendingBegins, tag.name)
+					begin.end = pkg.Fset.Position(c.Pos())
+					ranges = append(ranges, begin)
+				}
+				if !coverageEnabled && tag.kind != tagEnd {
+					// when in Should or May mode we still want to fail on missing tags
+					values[tag.name] = nil
+				}
+			}
+		}
+		commentMap[pathWithModule] = commentMapEntry
+
+		for _, decl := range syntax.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Doc == nil {
+				continue
+			}
+			for _, c := range fn.Doc.List {
+				if !strings.HasPrefix(c.Text, TagPrefix) {
+					continue
+				}
+				tag := parseTag(c.Text)
+				if tag.kind != tagFunc {
+					continue
+				}
+				ranges = append(ranges, aggregateRange{
+					name:  tag.name,
+					file:  pathWithModule,
+					start: pkg.Fset.Position(fn.Pos()),
+					end:   pkg.Fset.Position(fn.End()),
+				})
+				if !coverageEnabled {
+					values[tag.name] = nil
+				}
+			}
+		}
+	}
+	return commentMap, targetMap, ranges
+}
+
 var (
-	initCountersOnce  sync.Once
-	initCountersValue map[string]*uint32
+	initCountersOnce      sync.Once
+	initCountersValue     map[string][]*uint32
+	initCountersLocations map[string][]tagLocation
+	initCountersErr       error
 )
 
 // initCounters maps AST comment nodes tagged with //covers: tags to coverage counters
 // The AST parsing is performed once per package per test run.
-func initCounters(t testing.TB, coverageEnabled bool) map[string]*uint32 {
+func initCounters(t testing.TB, coverageEnabled bool) (map[string][]*uint32, map[string][]tagLocation, error) {
 	t.Helper()
 	initCountersOnce.Do(func() {
 		t.Helper()
@@ -186,81 +507,73 @@ func initCounters(t testing.TB, coverageEnabled bool) map[string]*uint32 {
 			t.Fatalf("packages.Load: %v", err)
 		}
 
-		values := make(map[string]*uint32) // tag key -> output values
+		// Go 1.20+ builds instrument "go test -cover" via runtime/coverage
+		// instead of populating the legacy testing.cover linkname, so the
+		// legacy block map is empty even though -cover is active. That
+		// scheme's counters are only flushed to disk at process exit (see
+		// ErrLiveCoverageUnavailable), so there is no live counter left to
+		// read here; fall back the same way as coverage being disabled, and
+		// report ErrLiveCoverageUnavailable instead of a count/hit value.
+		noLiveCounters := coverageEnabled && len(cover.Blocks) == 0
+
+		values := make(map[string][]*uint32)        // tag key -> output values
+		locations := make(map[string][]tagLocation) // tag key -> source blocks, for WriteProfile
 		for _, pkg := range pkgs {
+			commentMap, targetMap, ranges := scanPackageTags(t, pkg, coverageEnabled && !noLiveCounters, values)
 
-			commentMap := make(map[string][]*ast.Comment) // maps a file to the list of its tagged comments
-			targetMap := make(map[*ast.Comment]string)    // which output registers get incremented by a comment
-			dir := pkg.Module.Dir
-			path := pkg.Module.Path
-
-			for i, f := range pkg.CompiledGoFiles {
-				if strings.HasPrefix(f, dir) {
-					pathWithModule := strings.Replace(f, dir, path, 1)
-					syntax := pkg.Syntax[i]
-					commentMapEntry := commentMap[pathWithModule]
-					for _, commentGroup := range syntax.Comments {
-						for _, c := range commentGroup.List {
-							if strings.HasPrefix(c.Text, TagPrefix) {
-								commentMapEntry = append(commentMapEntry, c)
-								target := strings.TrimPrefix(c.Text, TagPrefix)
-								targetMap[c] = target
-								if !coverageEnabled {
-									// when in Should or May mode we still want to fail on missing tags
-									values[target] = nil
-								}
-							}
-						}
-					}
-					commentMap[pathWithModule] = commentMapEntry
-				}
-			}
-
-			if !coverageEnabled {
+			if !coverageEnabled || noLiveCounters {
 				continue
 			}
 
 			for file, blocks := range cover.Blocks {
-				commentMapEntry, ok := commentMap[file]
-				if !ok {
-					// t.Logf("no comment map for %s", file)
-					continue
-				}
+				commentMapEntry := commentMap[file]
 				for i, block := range blocks {
+					ctr := &cover.Counters[file][i]
+					blk := coverageBlock{
+						line0: int(block.Line0),
+						col0:  int(block.Col0),
+						line1: int(block.Line1),
+						col1:  int(block.Col1),
+					}
+					loc := tagLocation{file: file, coverageBlock: blk, numStmt: int(block.Stmts)}
+
 					for _, comment := range commentMapEntry {
 						commentPos := pkg.Fset.Position(comment.Pos())
-						if commentPos.Line < int(block.Line0) {
-							continue
-						}
-						if commentPos.Line > int(block.Line1) {
-							break // went far enough
-						}
-						if commentPos.Line == int(block.Line0) &&
-							commentPos.Column < int(block.Col0) {
-							continue
-						}
-						if commentPos.Line == int(block.Line1) &&
-							commentPos.Column > int(block.Col1) {
+						if !commentMatchesBlock(commentPos, blk) {
 							continue
 						}
-						ctr := &cover.Counters[file][i]
 						target, ok := targetMap[comment]
 						if !ok {
 							t.Fatalf("target not found for comment!")
 						}
 						// In tests there are two pkgs for each pkg - with and without tests
 						// We should probably only visit each file once!
-						if otherCtr, ok := values[target]; ok && otherCtr != ctr {
+						existing := values[target]
+						if len(existing) > 0 && existing[0] != ctr {
 							t.Fatalf("duplicated tag %s", comment.Text)
 						}
-						values[target] = ctr
+						if len(existing) == 0 {
+							values[target] = []*uint32{ctr}
+							locations[target] = []tagLocation{loc}
+						}
 						// t.Logf("comment %+v matched block %+v; tag %s", commentPos, block, target)
 					}
+
+					for _, rng := range ranges {
+						if rng.file != file || !rangeContainsBlock(rng.start, rng.end, blk) {
+							continue
+						}
+						addCounter(values, locations, rng.name, ctr, loc)
+					}
 				}
 			}
 		}
 		initCountersValue = values
+		initCountersLocations = locations
+		if noLiveCounters {
+			initCountersErr = ErrLiveCoverageUnavailable
+		}
 	})
 
-	return initCountersValue
+	return initCountersValue, initCountersLocations, initCountersErr
 }
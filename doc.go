@@ -1,6 +1,19 @@
 // Package covers contains a library for programatically asserting that a line of code is
 // covered by a test case via a machine-readable comments. See the tests for simple examples.
 //
+// # Live in-process coverage is not currently available
+//
+// Setup (and May/Should/Must, which wrap it) try to read coverage counters
+// out of the running test binary via "go test -cover", but every Go
+// toolchain currently supported by this package instruments "-cover" using
+// the runtime/coverage scheme, which only flushes counters to disk when the
+// process exits. There is no live per-block counter left to read mid-test,
+// so Setup always returns ErrLiveCoverageUnavailable, Must always fails the
+// test, and Should/May always return a Counters whose Tag/TagHit are no-ops.
+// Use FromProfile against a "-coverprofile" written by the same test run
+// instead; it is the only currently working way to assert on a //covers:
+// tag's execution.
+//
 // BE ADVISED: This package is under construction and the API may change in
 // backwards incompatible ways and without notice.
 package covers
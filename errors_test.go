@@ -0,0 +1,25 @@
+package covers_test
+
+import (
+	"errors"
+	"testing"
+
+	"jonwillia.ms/covers"
+)
+
+// TestSetupErrorsOnUnavailableLiveCoverage exercises the case this package
+// hits on every currently supported Go toolchain under "go test -cover":
+// testing.Cover.Blocks is empty because the test binary instruments
+// coverage via runtime/coverage instead, and that scheme only flushes
+// counters to disk when the process exits, so there is no live per-block
+// counter for Setup to read mid-test.
+func TestSetupErrorsOnUnavailableLiveCoverage(t *testing.T) {
+	if testing.CoverMode() == "" {
+		t.Skip("run with -cover to exercise this path")
+	}
+
+	_, err := covers.Setup(t)
+	if !errors.Is(err, covers.ErrLiveCoverageUnavailable) {
+		t.Fatalf("Setup: got %v, want ErrLiveCoverageUnavailable", err)
+	}
+}
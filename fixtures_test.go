@@ -0,0 +1,26 @@
+package covers
+
+// runFoobar gates whether One reaches foobarBody's "foobar" tag. One and Two
+// share this single tagged location, rather than each carrying their own
+// //covers:foobar comment, so the two fixtures can't collide over which one
+// "owns" the tag (initCounters treats two comments with the same name as a
+// duplicate tag). Exported because cover_test.go, an external (covers_test)
+// test, calls these as covers.One()/covers.Two(); they only exist in test
+// binaries.
+var runFoobar = false
+
+func foobarBody() {
+	_ = 1 //covers:foobar
+}
+
+// One is a cover_test.go fixture that never reaches the "foobar" tag.
+func One() {
+	if runFoobar {
+		foobarBody()
+	}
+}
+
+// Two is a cover_test.go fixture that reaches the "foobar" tag once per call.
+func Two() {
+	foobarBody()
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"testing"
+
+	"jonwillia.ms/covers"
+)
+
+func TestAggregateFixtures(t *testing.T) {
+	if got := Aggregated(5); got != 10 {
+		t.Fatalf("Aggregated(5) = %d, want 10", got)
+	}
+	if got := Aggregated(-1); got != -1 {
+		t.Fatalf("Aggregated(-1) = %d, want -1", got)
+	}
+	if got := BeginEnd(3); got != 4 {
+		t.Fatalf("BeginEnd(3) = %d, want 4", got)
+	}
+	if got := BeginEnd(20); got != 10 {
+		t.Fatalf("BeginEnd(20) = %d, want 10", got)
+	}
+}
+
+func TestFromProfileAggregatesFuncTag(t *testing.T) {
+	ctrs, err := covers.FromProfile(genProfile(t, "count", "TestAggregateFixtures"), "jonwillia.ms/covers")
+	if err != nil {
+		t.Fatalf("FromProfile: %v", err)
+	}
+	count, err := ctrs.Count("Aggregated")
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count < 2 {
+		t.Fatalf("Aggregated tag reported %d, want at least 2: both of its branches ran", count)
+	}
+}
+
+// TestBeginEndOuterBlockOnly calls BeginEnd with an input that never takes
+// its "if y > 10" branch, isolating the cover block that opens at
+// BeginEnd's "{" (and so starts before the //covers:begin comment) as the
+// only block in Ranged's range that ever executes. A rangeContainsBlock
+// that requires a block to start at-or-after the marker would drop that
+// block and report zero, even though BeginEnd genuinely ran.
+func TestBeginEndOuterBlockOnly(t *testing.T) {
+	if got := BeginEnd(3); got != 4 {
+		t.Fatalf("BeginEnd(3) = %d, want 4", got)
+	}
+}
+
+func TestFromProfileAggregatesBeginEndTag(t *testing.T) {
+	ctrs, err := covers.FromProfile(genProfile(t, "count", "TestBeginEndOuterBlockOnly"), "jonwillia.ms/covers")
+	if err != nil {
+		t.Fatalf("FromProfile: %v", err)
+	}
+	count, err := ctrs.Count("Ranged")
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count == 0 {
+		t.Fatal("Ranged tag reported zero, want nonzero: BeginEnd ran between its begin/end markers")
+	}
+}
@@ -0,0 +1,50 @@
+package test
+
+// runFoobar gates whether One reaches foobarBody's "foobar" tag. One and Two
+// share this single tagged location, rather than each carrying their own
+// //covers:foobar comment, so the two fixtures can't collide over which one
+// "owns" the tag (initCounters treats two comments with the same name as a
+// duplicate tag).
+var runFoobar = false
+
+func foobarBody() {
+	_ = 1 //covers:foobar
+}
+
+// One is a cover_test.go fixture that never reaches the "foobar" tag.
+func One() {
+	if runFoobar {
+		foobarBody()
+	}
+}
+
+// Two is a cover_test.go fixture that reaches the "foobar" tag once per call.
+func Two() {
+	foobarBody()
+}
+
+// Aggregated is an aggregate_test.go fixture for //covers:func: both of its
+// branches are cover blocks the tag should aggregate.
+//
+//covers:func Aggregated
+func Aggregated(n int) int {
+	if n > 0 {
+		return n * 2
+	}
+	return n
+}
+
+// BeginEnd is an aggregate_test.go fixture for //covers:begin/end: the blocks
+// between the markers should be aggregated under "Ranged" even though the
+// enclosing cover block starts before the //covers:begin comment.
+func BeginEnd(n int) int {
+	z := 0
+	_ = z
+	//covers:begin Ranged
+	y := n + 1
+	if y > 10 {
+		y = 10
+	}
+	//covers:end Ranged
+	return y
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"testing"
+
+	"jonwillia.ms/covers"
+)
+
+// TestFromProfileTwoCallsFixture calls Two() exactly twice, so
+// TestFromProfileCountMatchesExecutions can assert an exact count rather
+// than just "nonzero".
+func TestFromProfileTwoCallsFixture(t *testing.T) {
+	Two()
+	Two()
+}
+
+func TestFromProfileCountMatchesExecutions(t *testing.T) {
+	ctrs, err := covers.FromProfile(genProfile(t, "count", "TestFromProfileTwoCallsFixture"), "jonwillia.ms/covers")
+	if err != nil {
+		t.Fatalf("FromProfile: %v", err)
+	}
+
+	count, err := ctrs.Count("foobar")
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Count(\"foobar\") = %d, want 2: Two() ran twice", count)
+	}
+
+	hit, err := ctrs.Hit("foobar")
+	if err != nil {
+		t.Fatalf("Hit: %v", err)
+	}
+	if !hit {
+		t.Fatal(`Hit("foobar") = false, want true`)
+	}
+}
+
+// TestFromProfileOneNeverCallsFoobarFixture never reaches the "foobar" tag,
+// so TestFromProfileZeroCountIsNotHit has something to assert a negative
+// against.
+func TestFromProfileOneNeverCallsFoobarFixture(t *testing.T) {
+	One()
+}
+
+func TestFromProfileZeroCountIsNotHit(t *testing.T) {
+	ctrs, err := covers.FromProfile(genProfile(t, "count", "TestFromProfileOneNeverCallsFoobarFixture"), "jonwillia.ms/covers")
+	if err != nil {
+		t.Fatalf("FromProfile: %v", err)
+	}
+
+	count, err := ctrs.Count("foobar")
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Count(\"foobar\") = %d, want 0: One() never reaches it", count)
+	}
+
+	hit, err := ctrs.Hit("foobar")
+	if err != nil {
+		t.Fatalf("Hit: %v", err)
+	}
+	if hit {
+		t.Fatal(`Hit("foobar") = true, want false`)
+	}
+}
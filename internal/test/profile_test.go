@@ -0,0 +1,97 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"jonwillia.ms/covers"
+)
+
+// TestFoobarFixture just reaches the "foobar" tag twice, so genProfile has
+// something to produce a coverprofile from; it makes no assertions of its
+// own about coverage.
+func TestFoobarFixture(t *testing.T) {
+	Two()
+	Two()
+}
+
+// moduleRoot finds the repo root from this test file's own path, so the
+// coverprofile genProfile generates and FromProfile's packages.Load agree on
+// where the module lives.
+func moduleRoot(t *testing.T) string {
+	t.Helper()
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("runtime.Caller failed")
+	}
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
+
+// genProfile runs "go test -coverprofile" over this package in a subprocess
+// and returns the resulting coverprofile's path, the same way a CI pipeline
+// produces the artifact FromProfile is meant to consume.
+func genProfile(t *testing.T, mode, run string) string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not on PATH")
+	}
+
+	profile := filepath.Join(t.TempDir(), "c.out")
+	cmd := exec.Command(goBin, "test", "-run", run, "-covermode="+mode, "-coverprofile="+profile, ".")
+	cmd.Dir = filepath.Join(moduleRoot(t), "internal", "test")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test -coverprofile: %v\n%s", err, out)
+	}
+	return profile
+}
+
+// TestWriteProfileIsValidCoverprofile round-trips a FromProfile Counters'
+// WriteProfile output through "go tool cover -func=", which rejects
+// anything but the exact "file:line.col,line.col numStmt count" format.
+func TestWriteProfileIsValidCoverprofile(t *testing.T) {
+	ctrs, err := covers.FromProfile(genProfile(t, "count", "TestFoobarFixture"), "jonwillia.ms/covers")
+	if err != nil {
+		t.Fatalf("FromProfile: %v", err)
+	}
+
+	var buf strings.Builder
+	if err := ctrs.WriteProfile(&buf); err != nil {
+		t.Fatalf("WriteProfile: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if lines[0] != "mode: count" {
+		t.Fatalf("first line = %q, want mode header", lines[0])
+	}
+	dataLine := regexp.MustCompile(`^.+:[0-9]+\.[0-9]+,[0-9]+\.[0-9]+ [0-9]+ [0-9]+$`)
+	for _, line := range lines[1:] {
+		if !dataLine.MatchString(line) {
+			t.Fatalf("line %q is not a valid coverprofile data line (go tool cover would reject it)", line)
+		}
+	}
+
+	tags := ctrs.ProfileTags()
+	if len(tags["foobar"]) == 0 {
+		t.Fatal(`ProfileTags()["foobar"] is empty`)
+	}
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not on PATH")
+	}
+	out := filepath.Join(t.TempDir(), "tagged.out")
+	if err := os.WriteFile(out, []byte(buf.String()), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cmd := exec.Command(goBin, "tool", "cover", "-func="+out)
+	cmd.Dir = moduleRoot(t)
+	if combined, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go tool cover -func rejected WriteProfile's output: %v\n%s", err, combined)
+	}
+}
@@ -0,0 +1,121 @@
+package covers
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// profileTB is the Helper/Fatalf adapter Counters built by FromProfile use
+// in place of a *testing.T, which they don't have: FromProfile runs as a CI
+// post-processing step, not inside "go test". Fatalf panics rather than
+// failing a test; Count and Hit are the error-returning way to read a
+// FromProfile Counters without risking that panic. Tag and TagHit do not
+// panic as long as the tag is known and the mode matches, but they compare
+// against the Snapshot FromProfile takes after loading the profile, and the
+// loaded counts never change, so Tag always reports 0 and TagHit false.
+type profileTB struct{}
+
+func (profileTB) Helper() {}
+
+func (profileTB) Fatalf(format string, args ...interface{}) {
+	panic(fmt.Errorf(format, args...))
+}
+
+// FromProfile loads a Counters from a saved go coverprofile (e.g. the
+// output of "go test ./... -coverprofile=c.out") instead of a live test
+// binary, using the same "mode: ..." / "file:line.col,line.col n c" grammar
+// go tool cover consumes. modulePath is the module whose //covers: tags
+// should be resolved, loaded via packages.Load exactly as initCounters
+// does. This makes covers usable as a CI step over an artifact, or against
+// code under test that runs in a separate process (integration tests, "go
+// test -c" binaries) where the testing.cover linkname returns nothing.
+func FromProfile(profilePath, modulePath string) (*Counters, error) {
+	data, err := os.ReadFile(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("covers: reading profile: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "mode:") {
+		return nil, fmt.Errorf("covers: %s: missing \"mode:\" header", profilePath)
+	}
+	mode := strings.TrimSpace(strings.TrimPrefix(lines[0], "mode:"))
+
+	byFile := make(map[string][]profileBlock)
+	for _, line := range lines[1:] {
+		pl, ok := parseProfileLine(line)
+		if !ok {
+			continue
+		}
+		byFile[pl.file] = append(byFile[pl.file], profileBlock{
+			coverageBlock: coverageBlock{line0: pl.line0, col0: pl.col0, line1: pl.line1, col1: pl.col1},
+			numStmt:       pl.numStmt,
+			count:         pl.count,
+		})
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.NeedSyntax |
+			packages.NeedModule |
+			packages.NeedCompiledGoFiles |
+			packages.NeedFiles |
+			packages.NeedTypes,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, path.Join(modulePath, "..."))
+	if err != nil {
+		return nil, fmt.Errorf("covers: packages.Load: %w", err)
+	}
+
+	values := make(map[string][]*uint32)
+	locations := make(map[string][]tagLocation)
+	cache := newBlockCounterCache()
+
+	for _, pkg := range pkgs {
+		commentMap, targetMap, ranges := scanPackageTags(profileTB{}, pkg, true, values)
+		matchProfileBlocks(profileTB{}, pkg.Fset, commentMap, targetMap, ranges, byFile, cache.counterFor, values, locations)
+	}
+
+	c := &Counters{
+		tb:        profileTB{},
+		counters:  values,
+		locations: locations,
+		isEnabled: true,
+		mode:      mode,
+	}
+	c.Snapshot = c.NewSnapshot()
+	return c, nil
+}
+
+// Count returns tag's counter value (summed across its blocks if it
+// aggregates several, via //covers:func or //covers:begin/end) as recorded
+// by the Counters' source profile, or an error if tag was never resolved.
+// It is the error-returning counterpart to Tag, meant for Counters built by
+// FromProfile, which have no live *testing.T to fail instead.
+func (c *Counters) Count(tag string) (uint32, error) {
+	addrs, ok := c.counters[tag]
+	if !ok {
+		return 0, fmt.Errorf("covers: tag not found: %s", tag)
+	}
+	var sum uint32
+	for _, addr := range addrs {
+		sum += atomic.LoadUint32(addr)
+	}
+	return sum, nil
+}
+
+// Hit reports whether tag's block(s) ever executed. It is Count's boolean
+// form, useful under any -covermode including "set", where only Count's raw
+// sum isn't meaningful.
+func (c *Counters) Hit(tag string) (bool, error) {
+	count, err := c.Count(tag)
+	if err != nil {
+		return false, err
+	}
+	return count != 0, nil
+}
@@ -0,0 +1,47 @@
+package covers_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jonwillia.ms/covers"
+)
+
+func TestFromProfileMissingFile(t *testing.T) {
+	_, err := covers.FromProfile(filepath.Join(t.TempDir(), "does-not-exist.out"), "jonwillia.ms/covers")
+	if err == nil {
+		t.Fatal("FromProfile: got nil error for a nonexistent profile, want an error")
+	}
+}
+
+func TestFromProfileMissingModeHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.out")
+	if err := os.WriteFile(path, []byte("not a coverprofile\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	_, err := covers.FromProfile(path, "jonwillia.ms/covers")
+	if err == nil {
+		t.Fatal("FromProfile: got nil error for a profile with no \"mode:\" header, want an error")
+	}
+}
+
+func TestCountAndHitUnknownTag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.out")
+	if err := os.WriteFile(path, []byte("mode: count\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	ctrs, err := covers.FromProfile(path, "jonwillia.ms/covers")
+	if err != nil {
+		t.Fatalf("FromProfile: %v", err)
+	}
+
+	if _, err := ctrs.Count("nonexistent"); err == nil {
+		t.Fatal("Count: got nil error for an unknown tag, want an error")
+	}
+	if _, err := ctrs.Hit("nonexistent"); err == nil {
+		t.Fatal("Hit: got nil error for an unknown tag, want an error")
+	}
+}
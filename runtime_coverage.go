@@ -0,0 +1,188 @@
+package covers
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+	"sync/atomic"
+)
+
+// coverageBlock is the source range of a single coverage-instrumented basic
+// block, expressed the same way regardless of which Go coverage backend
+// produced it.
+type coverageBlock struct {
+	line0, col0, line1, col1 int
+}
+
+// commentMatchesBlock reports whether a comment's position falls within a
+// coverage block's range, using the same rule initCounters applies to
+// testing.CoverBlock.
+func commentMatchesBlock(pos token.Position, b coverageBlock) bool {
+	if pos.Line < b.line0 {
+		return false
+	}
+	if pos.Line > b.line1 {
+		return false
+	}
+	if pos.Line == b.line0 && pos.Column < b.col0 {
+		return false
+	}
+	if pos.Line == b.line1 && pos.Column > b.col1 {
+		return false
+	}
+	return true
+}
+
+// rangeContainsBlock reports whether a coverage block overlaps [start, end],
+// the bounds of a //covers:func or //covers:begin/end tag. This is an
+// overlap test, not strict containment: a Go coverage block starts at the
+// previous branch point rather than at the //covers:begin comment itself, so
+// the block enclosing the marker routinely starts before start. Requiring
+// b.line0/col0 to be at-or-after start (as a containment test would) drops
+// that block, and with it the only counter that actually covers the tagged
+// code, producing a silent false negative.
+func rangeContainsBlock(start, end token.Position, b coverageBlock) bool {
+	endsBeforeStart := b.line1 < start.Line || (b.line1 == start.Line && b.col1 < start.Column)
+	startsAfterEnd := b.line0 > end.Line || (b.line0 == end.Line && b.col0 > end.Column)
+	return !endsBeforeStart && !startsAfterEnd
+}
+
+// blockKey identifies a coverage block the same way a coverprofile line
+// does, so blocks read back from a saved profile can be matched against the
+// positions AST comments report.
+func blockKey(file string, b coverageBlock) string {
+	return fmt.Sprintf("%s:%d.%d,%d.%d", file, b.line0, b.col0, b.line1, b.col1)
+}
+
+// blockCounterCache hands out one owned *uint32 per distinct coverage block
+// under a tag, regardless of how many times that block is matched (e.g.
+// because a package is loaded twice, with and without its test variant). It
+// backs FromProfile, whose counters are seeded once from a saved profile and
+// never change again.
+type blockCounterCache struct {
+	targets map[string][]*uint32 // tag -> owned counters
+	keys    map[string][]string  // tag -> profile keys, parallel to targets
+}
+
+func newBlockCounterCache() *blockCounterCache {
+	return &blockCounterCache{
+		targets: make(map[string][]*uint32),
+		keys:    make(map[string][]string),
+	}
+}
+
+// counterFor returns the owned *uint32 for one profile block under tag,
+// allocating and seeding it from b the first time the block is seen.
+func (c *blockCounterCache) counterFor(tag, file string, b profileBlock) *uint32 {
+	key := blockKey(file, b.coverageBlock)
+	for i, k := range c.keys[tag] {
+		if k == key {
+			return c.targets[tag][i]
+		}
+	}
+	addr := new(uint32)
+	atomic.StoreUint32(addr, uint32(b.count))
+	c.targets[tag] = append(c.targets[tag], addr)
+	c.keys[tag] = append(c.keys[tag], key)
+	return addr
+}
+
+// counterAllocator returns the owned *uint32 a matched (tag, file, block)
+// triple should record its count in.
+type counterAllocator func(tag, file string, b profileBlock) *uint32
+
+// matchProfileBlocks matches commentMap's plain tags and ranges' aggregate
+// tags against a []profileBlock view of coverage, allocating a counter for
+// each match via alloc and recording it (and its source location) in
+// values/locations. It backs FromProfile, which works from a parsed
+// coverprofile rather than testing.cover's addressable globals.
+func matchProfileBlocks(t tHelper, fset *token.FileSet, commentMap map[string][]*ast.Comment, targetMap map[*ast.Comment]string, ranges []aggregateRange, byFile map[string][]profileBlock, alloc counterAllocator, values map[string][]*uint32, locations map[string][]tagLocation) {
+	t.Helper()
+
+	for file, comments := range commentMap {
+		blocks, ok := byFile[file]
+		if !ok {
+			continue
+		}
+		for _, comment := range comments {
+			commentPos := fset.Position(comment.Pos())
+			for _, b := range blocks {
+				if !commentMatchesBlock(commentPos, b.coverageBlock) {
+					continue
+				}
+				target := targetMap[comment]
+				addr := alloc(target, file, b)
+				existing := values[target]
+				if len(existing) > 0 && existing[0] != addr {
+					t.Fatalf("duplicated tag %s", comment.Text)
+				}
+				if len(existing) == 0 {
+					values[target] = []*uint32{addr}
+					locations[target] = []tagLocation{{file: file, coverageBlock: b.coverageBlock, numStmt: b.numStmt}}
+				}
+			}
+		}
+	}
+
+	for _, rng := range ranges {
+		blocks, ok := byFile[rng.file]
+		if !ok {
+			continue
+		}
+		for _, b := range blocks {
+			if !rangeContainsBlock(rng.start, rng.end, b.coverageBlock) {
+				continue
+			}
+			addr := alloc(rng.name, rng.file, b)
+			addCounter(values, locations, rng.name, addr, tagLocation{file: rng.file, coverageBlock: b.coverageBlock, numStmt: b.numStmt})
+		}
+	}
+}
+
+// profileBlock is one data line of a standard go coverage profile: a block
+// range plus the number of statements and times it executed.
+type profileBlock struct {
+	coverageBlock
+	numStmt int
+	count   int64
+}
+
+// parsedProfileLine is one parsed data line of a standard go coverage
+// profile, e.g. "jonwillia.ms/covers/internal/test/two.go:7.2,9.16 1 2".
+type parsedProfileLine struct {
+	file                     string
+	line0, col0, line1, col1 int
+	numStmt                  int
+	count                    int64
+}
+
+// parseProfileLine parses one line of a go coverage profile. The "mode:"
+// header and blank lines report ok == false.
+func parseProfileLine(line string) (pl parsedProfileLine, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "mode:") {
+		return parsedProfileLine{}, false
+	}
+
+	colon := strings.LastIndex(line, ":")
+	if colon < 0 {
+		return parsedProfileLine{}, false
+	}
+	fields := strings.Fields(line[colon+1:])
+	if len(fields) != 3 {
+		return parsedProfileLine{}, false
+	}
+
+	pl.file = line[:colon]
+	if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &pl.line0, &pl.col0, &pl.line1, &pl.col1); err != nil {
+		return parsedProfileLine{}, false
+	}
+	if _, err := fmt.Sscanf(fields[1], "%d", &pl.numStmt); err != nil {
+		return parsedProfileLine{}, false
+	}
+	if _, err := fmt.Sscanf(fields[2], "%d", &pl.count); err != nil {
+		return parsedProfileLine{}, false
+	}
+	return pl, true
+}
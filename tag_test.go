@@ -0,0 +1,57 @@
+package covers
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTagNoOpWhenCoverageDisabledUnderSetMode reproduces the shape Setup
+// actually produces under "go test -cover" (covermode defaults to "set") on
+// every currently supported Go toolchain: live coverage is unavailable (see
+// ErrLiveCoverageUnavailable), so counters[tag] is registered but empty,
+// while mode is still set to whatever testing.CoverMode() reports. Tag must
+// no-op on the empty-addrs case before it ever looks at mode, the same way
+// TagHit does; checking mode first means Should+Tag Fatals on every build,
+// including covermode=set, rather than silently doing nothing like the
+// package doc promises.
+func TestTagNoOpWhenCoverageDisabledUnderSetMode(t *testing.T) {
+	fc := &fatalCapture{}
+	c := &Counters{
+		tb:       fc,
+		counters: map[string][]*uint32{"tag": nil},
+		mode:     "set",
+	}
+	c.Snapshot = c.NewSnapshot()
+
+	called := false
+	c.Tag("tag", func(uint32) { called = true })
+	if called {
+		t.Fatal("Tag invoked f with coverage disabled, want no-op")
+	}
+	if fc.msg != "" {
+		t.Fatalf("Tag called Fatalf with coverage disabled: %s", fc.msg)
+	}
+}
+
+func TestTagFailsUnderSetModeWithRealCounters(t *testing.T) {
+	fc := &fatalCapture{}
+	a := new(uint32)
+	c := &Counters{
+		tb:        fc,
+		counters:  map[string][]*uint32{"tag": {a}},
+		isEnabled: true,
+		mode:      "set",
+	}
+	c.Snapshot = c.NewSnapshot()
+
+	defer func() {
+		r := recover()
+		if r != fc {
+			t.Fatalf("Tag did not Fatalf under -covermode=set, recovered %v", r)
+		}
+		if !strings.Contains(fc.msg, "Tag needs a counting -covermode") {
+			t.Fatalf("unexpected Fatalf message: %s", fc.msg)
+		}
+	}()
+	c.Tag("tag", func(uint32) {})
+}
@@ -0,0 +1,104 @@
+package covers
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fatalCapture is a tHelper that records a Fatalf call instead of failing
+// the process, so tests can assert on the wrong-mode/missing-tag paths that
+// Tag and TagHit report via Fatalf.
+type fatalCapture struct{ msg string }
+
+func (f *fatalCapture) Helper() {}
+
+func (f *fatalCapture) Fatalf(format string, args ...interface{}) {
+	f.msg = fmt.Sprintf(format, args...)
+	panic(f)
+}
+
+// newSetCounters builds a Counters in -covermode=set with addrs registered
+// under tag, bypassing Setup/initCounters so the live-coverage gap doesn't
+// get in the way of testing TagHit's own logic.
+func newSetCounters(tb tHelper, tag string, addrs []*uint32) *Counters {
+	c := &Counters{
+		tb:        tb,
+		counters:  map[string][]*uint32{tag: addrs},
+		isEnabled: true,
+		mode:      "set",
+	}
+	c.Snapshot = c.NewSnapshot()
+	return c
+}
+
+func TestTagHitReportsAggregateHit(t *testing.T) {
+	a, b := new(uint32), new(uint32)
+	c := newSetCounters(t, "tag", []*uint32{a, b})
+
+	atomic.StoreUint32(b, 1)
+
+	var hit bool
+	c.TagHit("tag", func(h bool) { hit = h })
+	if !hit {
+		t.Fatal("TagHit reported false, want true: one of the aggregated counters transitioned")
+	}
+}
+
+func TestTagHitNoHitWhenNothingTransitioned(t *testing.T) {
+	a := new(uint32)
+	c := newSetCounters(t, "tag", []*uint32{a})
+
+	var hit bool
+	c.TagHit("tag", func(h bool) { hit = h })
+	if hit {
+		t.Fatal("TagHit reported true, want false: the counter never transitioned since the snapshot")
+	}
+}
+
+func TestTagHitFailsUnderCountingMode(t *testing.T) {
+	fc := &fatalCapture{}
+	a := new(uint32)
+	c := &Counters{
+		tb:        fc,
+		counters:  map[string][]*uint32{"tag": {a}},
+		isEnabled: true,
+		mode:      "count",
+	}
+	c.Snapshot = c.NewSnapshot()
+
+	defer func() {
+		r := recover()
+		if r != fc {
+			t.Fatalf("TagHit did not Fatalf under -covermode=count, recovered %v", r)
+		}
+		if !strings.Contains(fc.msg, "TagHit needs -covermode=set") {
+			t.Fatalf("unexpected Fatalf message: %s", fc.msg)
+		}
+	}()
+	c.TagHit("tag", func(bool) {})
+}
+
+// TestTagHitNoOpWhenCoverageDisabled covers the case initCounters reports by
+// registering a tag with no counters at all (the "coverage is off" path):
+// TagHit must no-op rather than Fatalf on the mode mismatch, the same way
+// Tag does.
+func TestTagHitNoOpWhenCoverageDisabled(t *testing.T) {
+	fc := &fatalCapture{}
+	c := &Counters{
+		tb:       fc,
+		counters: map[string][]*uint32{"tag": nil},
+		mode:     "count",
+	}
+	c.Snapshot = c.NewSnapshot()
+
+	called := false
+	c.TagHit("tag", func(bool) { called = true })
+	if called {
+		t.Fatal("TagHit invoked f with coverage disabled, want no-op")
+	}
+	if fc.msg != "" {
+		t.Fatalf("TagHit called Fatalf with coverage disabled: %s", fc.msg)
+	}
+}